@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// messagesBucket is the single bbolt bucket used to cache fetched message
+// metadata, keyed by Gmail message ID.
+var messagesBucket = []byte("messages")
+
+// cachedMessage is the subset of a gmail.Message that's worth persisting
+// across runs: the fields getSpamCounts actually reads.
+type cachedMessage struct {
+	ID              string `json:"id"`
+	InternalDate    int64  `json:"internal_date"`
+	From            string `json:"from"`
+	Subject         string `json:"subject"`
+	ListUnsubscribe string `json:"list_unsubscribe"`
+}
+
+// openCache opens (creating if necessary) the bbolt cache file at path and
+// ensures the messages bucket exists.
+func openCache(path string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open cache file %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(messagesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to initialize cache bucket: %v", err)
+	}
+	return db, nil
+}
+
+// getCachedMessage returns the cached metadata for id, if present.
+func getCachedMessage(db *bbolt.DB, id string) (*cachedMessage, bool, error) {
+	var msg *cachedMessage
+	err := db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(messagesBucket).Get([]byte(id))
+		if v == nil {
+			return nil
+		}
+		msg = &cachedMessage{}
+		return json.Unmarshal(v, msg)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return msg, msg != nil, nil
+}
+
+// putCachedMessage stores msg in the cache, keyed by its ID.
+func putCachedMessage(db *bbolt.DB, msg *cachedMessage) error {
+	v, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(messagesBucket).Put([]byte(msg.ID), v)
+	})
+}
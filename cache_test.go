@@ -0,0 +1,70 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenCacheCreatesBucket(t *testing.T) {
+	db, err := openCache(filepath.Join(t.TempDir(), "spamcache.db"))
+	if err != nil {
+		t.Fatalf("openCache failed: %v", err)
+	}
+	defer db.Close()
+
+	if _, ok, err := getCachedMessage(db, "missing"); err != nil {
+		t.Fatalf("getCachedMessage on empty bucket failed: %v", err)
+	} else if ok {
+		t.Fatalf("expected no cached message, got a hit")
+	}
+}
+
+func TestPutAndGetCachedMessage(t *testing.T) {
+	db, err := openCache(filepath.Join(t.TempDir(), "spamcache.db"))
+	if err != nil {
+		t.Fatalf("openCache failed: %v", err)
+	}
+	defer db.Close()
+
+	want := &cachedMessage{
+		ID:              "msg-1",
+		InternalDate:    1577936645000,
+		From:            "spammer@example.com",
+		Subject:         "Buy now",
+		ListUnsubscribe: "<mailto:unsub@example.com>",
+	}
+	if err := putCachedMessage(db, want); err != nil {
+		t.Fatalf("putCachedMessage failed: %v", err)
+	}
+
+	got, ok, err := getCachedMessage(db, "msg-1")
+	if err != nil {
+		t.Fatalf("getCachedMessage failed: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected a cache hit for msg-1")
+	}
+	if *got != *want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestGetCachedMessageMiss(t *testing.T) {
+	db, err := openCache(filepath.Join(t.TempDir(), "spamcache.db"))
+	if err != nil {
+		t.Fatalf("openCache failed: %v", err)
+	}
+	defer db.Close()
+
+	if err := putCachedMessage(db, &cachedMessage{ID: "msg-1"}); err != nil {
+		t.Fatalf("putCachedMessage failed: %v", err)
+	}
+
+	_, ok, err := getCachedMessage(db, "msg-2")
+	if err != nil {
+		t.Fatalf("getCachedMessage failed: %v", err)
+	}
+	if ok {
+		t.Fatalf("expected a cache miss for msg-2")
+	}
+}
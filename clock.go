@@ -0,0 +1,23 @@
+package main
+
+import "time"
+
+// Clock abstracts the parts of the time package this program depends on for
+// wall-clock behavior, so cutoff-date bucketing, retry backoff, and the
+// OAuth callback wait can be exercised deterministically in tests.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the production Clock, backed directly by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clock is the Clock used throughout the program; tests may swap it for a
+// fake to control time deterministically.
+var clock Clock = realClock{}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a deterministic Clock for tests: Now returns a fixed instant,
+// and Sleep/After return immediately instead of actually waiting.
+type fakeClock struct {
+	now time.Time
+}
+
+func (f fakeClock) Now() time.Time { return f.now }
+
+func (f fakeClock) Sleep(d time.Duration) {}
+
+func (f fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- f.now.Add(d)
+	return ch
+}
+
+func TestCutoffSinceMonthRollover(t *testing.T) {
+	now := time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)
+	got := cutoffSince(now, 5)
+	want := time.Date(2024, 2, 26, 10, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestCutoffSinceDSTSpringForward(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+	// US DST "spring forward" happened at 2am on 2024-03-10.
+	now := time.Date(2024, 3, 11, 9, 0, 0, 0, loc)
+	got := cutoffSince(now, 2)
+	want := time.Date(2024, 3, 9, 9, 0, 0, 0, loc)
+	if !got.Equal(want) {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestRetryWithBackoffExhaustsAttempts(t *testing.T) {
+	orig := clock
+	clock = fakeClock{now: time.Now()}
+	defer func() { clock = orig }()
+
+	attempts := 0
+	err := retryWithBackoff(context.Background(), func() error {
+		attempts++
+		return errors.New("boom")
+	})
+	if err == nil {
+		t.Fatalf("expected an error after exhausting retries")
+	}
+	if attempts != 8 {
+		t.Fatalf("expected 8 attempts, got %d", attempts)
+	}
+}
+
+func TestRetryWithBackoffSucceedsEventually(t *testing.T) {
+	orig := clock
+	clock = fakeClock{now: time.Now()}
+	defer func() { clock = orig }()
+
+	attempts := 0
+	err := retryWithBackoff(context.Background(), func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+}
@@ -151,7 +151,7 @@ func getTokenFromWeb(ctx context.Context, config *oauth2.Config) (*oauth2.Token,
 	// Wait for the authorization code.
 	var authCode string
 	select {
-	case <-time.After(5 * time.Minute):
+	case <-clock.After(5 * time.Minute):
 		// Gracefully stop the HTTP server and return an error
 		stdinCancel()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// Gmail's documented per-user quota is 250 quota units/second, and a
+// Messages.get call costs 5 units, so the client can safely sustain 50
+// requests/second without tripping userRateLimitExceeded.
+// See https://developers.google.com/gmail/api/reference/quota
+const gmailQuotaUnitsPerSecond = 250
+const messagesGetQuotaCost = 5
+const maxWorkers = 8
+
+// GmailSource lists spam messages via the Gmail API, caching fetched
+// metadata in a local bbolt store so repeated runs don't re-fetch messages
+// already seen.
+type GmailSource struct {
+	srv *gmail.Service
+	db  *bbolt.DB
+}
+
+// NewGmailSource returns a SpamSource backed by the Gmail API.
+func NewGmailSource(srv *gmail.Service, db *bbolt.DB) *GmailSource {
+	return &GmailSource{srv: srv, db: db}
+}
+
+// ListSpamSince implements SpamSource.
+func (g *GmailSource) ListSpamSince(ctx context.Context, since time.Time) ([]SpamMessage, error) {
+	cached, err := g.listSpamMessages(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]SpamMessage, 0, len(cached))
+	for _, m := range cached {
+		messages = append(messages, SpamMessage{
+			ID:           m.ID,
+			InternalDate: m.InternalDate,
+			From:         m.From,
+			Subject:      m.Subject,
+		})
+	}
+	return messages, nil
+}
+
+func (g *GmailSource) listSpamMessages(ctx context.Context, since time.Time) ([]*cachedMessage, error) {
+	var messages []*cachedMessage
+	pageToken := ""
+
+	// We'll collect full messages into `messages` but fetch them using a
+	// bounded worker pool to avoid launching an unbounded number of
+	// goroutines. Use errgroup for easier error handling.
+
+	query := "after:" + since.Format("2006-01-02") // Gmail query to filter messages
+	fmt.Printf("Gmail query: %s\n", query)
+	total := 0
+
+	// Use a cancellable context with timeout so the whole listing/fetching
+	// process respects the -timeout flag.
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(*timeout)*time.Second)
+	defer cancel()
+
+	// Self-throttle to Gmail's documented quota instead of jittering with
+	// random sleeps, and bound the number of concurrent fetches.
+	limiter := rate.NewLimiter(rate.Limit(gmailQuotaUnitsPerSecond/messagesGetQuotaCost), gmailQuotaUnitsPerSecond/messagesGetQuotaCost)
+
+	var mu sync.Mutex
+	var eg errgroup.Group
+	eg.SetLimit(maxWorkers)
+
+	for {
+		req := g.srv.Users.Messages.List("me").LabelIds("SPAM").Q(query)
+		if pageToken != "" {
+			req = req.PageToken(pageToken)
+		}
+
+		var listResp *gmail.ListMessagesResponse
+		// Wrap the request with a context check so we exit quickly if the
+		// parent context is cancelled.
+		if err := retryWithBackoff(ctx, func() error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			var err error
+			listResp, err = req.Do()
+			if err != nil && *debug {
+				log.Printf("Error fetching messages list: %v", err)
+			}
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("error fetching messages: %v", err)
+		}
+
+		// Process messages with bounded concurrency
+		for _, msg := range listResp.Messages {
+			m := msg
+			total++
+			fmt.Printf("\r%d", total)
+
+			// Consult the cache first; only hit the API for messages we
+			// haven't seen before (or when -refresh forces a re-fetch).
+			if !*refresh {
+				if cached, ok, err := getCachedMessage(g.db, m.Id); err != nil {
+					if *debug {
+						log.Printf("Error reading cache for message %s: %v", m.Id, err)
+					}
+				} else if ok {
+					mu.Lock()
+					messages = append(messages, cached)
+					mu.Unlock()
+					continue
+				}
+			}
+
+			eg.Go(func() error {
+				if err := limiter.Wait(ctx); err != nil {
+					return err
+				}
+
+				var fullMsg *gmail.Message
+				if err := retryWithBackoff(ctx, func() error {
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					default:
+					}
+					var err error
+					fullMsg, err = g.srv.Users.Messages.Get("me", m.Id).
+						Format("metadata").
+						MetadataHeaders("From", "Subject", "List-Unsubscribe").
+						Do()
+					if err != nil && *debug {
+						log.Printf("Error fetching message %s: %v", m.Id, err)
+					}
+					return err
+				}); err != nil {
+					if *debug {
+						log.Printf("Failed to fetch message %s: %v", m.Id, err)
+					}
+					return nil // non-fatal; continue with other messages
+				}
+
+				if fullMsg != nil {
+					cached := toCachedMessage(fullMsg)
+					if err := putCachedMessage(g.db, cached); err != nil && *debug {
+						log.Printf("Error writing cache for message %s: %v", m.Id, err)
+					}
+					mu.Lock()
+					messages = append(messages, cached)
+					mu.Unlock()
+				}
+				return nil
+			})
+		}
+
+		pageToken = listResp.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+
+	fmt.Print("\r") // erase the in progress count
+
+	// Wait for all workers to finish (or context timeout)
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// headerValue returns the value of the named header on m's top-level
+// payload, or "" if the message has no such header.
+func headerValue(m *gmail.Message, name string) string {
+	if m.Payload == nil {
+		return ""
+	}
+	for _, h := range m.Payload.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// toCachedMessage extracts the fields getSpamCounts needs out of a full
+// gmail.Message, for storage in the local cache.
+func toCachedMessage(m *gmail.Message) *cachedMessage {
+	return &cachedMessage{
+		ID:              m.Id,
+		InternalDate:    m.InternalDate,
+		From:            headerValue(m, "From"),
+		Subject:         headerValue(m, "Subject"),
+		ListUnsubscribe: headerValue(m, "List-Unsubscribe"),
+	}
+}
+
+// retryWithBackoff retries op with exponential backoff, but only for errors
+// classified as transient by classifyError; it gives up immediately on
+// anything else (in particular 401/403/404 auth and permission failures).
+// A server-supplied Retry-After is honored in place of the computed backoff.
+func retryWithBackoff(ctx context.Context, op func() error) error {
+	wait := 300 * time.Millisecond
+	maxAttempts := 8
+	for i := 0; i < maxAttempts; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		err := op()
+		if err == nil {
+			return nil
+		}
+
+		retryAfter, retryable := classifyError(err)
+		if !retryable || i == maxAttempts-1 {
+			return err
+		}
+
+		sleep := wait
+		if retryAfter > 0 {
+			sleep = retryAfter
+		}
+		clock.Sleep(sleep)
+		wait *= 2
+		if wait > 10*time.Second {
+			wait = 10 * time.Second
+		}
+	}
+	return fmt.Errorf("retry attempts exhausted")
+}
+
+// classifyError reports whether err looks like a transient Gmail API error
+// worth retrying (429/500/502/503/504, or a 403 reported as a rate-limit
+// reason), and how long the server asked us to wait before retrying, via
+// Retry-After, if it said (0 otherwise). Non-googleapi errors, such as
+// network or context errors, are treated as retryable.
+func classifyError(err error) (retryAfter time.Duration, retryable bool) {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return 0, true
+	}
+
+	switch gerr.Code {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		retryable = true
+	case http.StatusForbidden:
+		for _, e := range gerr.Errors {
+			if e.Reason == "rateLimitExceeded" || e.Reason == "userRateLimitExceeded" {
+				retryable = true
+				break
+			}
+		}
+	}
+
+	if retryable {
+		if ra := gerr.Header.Get("Retry-After"); ra != "" {
+			if secs, err := strconv.Atoi(ra); err == nil {
+				retryAfter = time.Duration(secs) * time.Second
+			} else if t, err := http.ParseTime(ra); err == nil {
+				retryAfter = time.Until(t)
+			}
+		}
+	}
+
+	return retryAfter, retryable
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestClassifyErrorNonGoogleapi(t *testing.T) {
+	retryAfter, retryable := classifyError(errors.New("connection reset"))
+	if !retryable {
+		t.Fatalf("expected a non-googleapi error to be treated as retryable")
+	}
+	if retryAfter != 0 {
+		t.Fatalf("expected no Retry-After for a non-googleapi error, got %v", retryAfter)
+	}
+}
+
+func TestClassifyErrorRateLimited(t *testing.T) {
+	err := &googleapi.Error{
+		Code:   http.StatusTooManyRequests,
+		Header: http.Header{"Retry-After": []string{"30"}},
+	}
+	retryAfter, retryable := classifyError(err)
+	if !retryable {
+		t.Fatalf("expected 429 to be retryable")
+	}
+	if retryAfter != 30*time.Second {
+		t.Fatalf("expected a 30s Retry-After, got %v", retryAfter)
+	}
+}
+
+func TestClassifyErrorForbiddenRateLimitReason(t *testing.T) {
+	err := &googleapi.Error{
+		Code:   http.StatusForbidden,
+		Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}},
+	}
+	retryAfter, retryable := classifyError(err)
+	if !retryable {
+		t.Fatalf("expected a 403 userRateLimitExceeded to be retryable")
+	}
+	if retryAfter != 0 {
+		t.Fatalf("expected no Retry-After header, got %v", retryAfter)
+	}
+}
+
+func TestClassifyErrorForbiddenOtherReason(t *testing.T) {
+	err := &googleapi.Error{
+		Code:   http.StatusForbidden,
+		Errors: []googleapi.ErrorItem{{Reason: "insufficientPermissions"}},
+	}
+	_, retryable := classifyError(err)
+	if retryable {
+		t.Fatalf("expected a 403 with an unrelated reason to not be retryable")
+	}
+}
+
+func TestClassifyErrorNotFound(t *testing.T) {
+	err := &googleapi.Error{Code: http.StatusNotFound}
+	_, retryable := classifyError(err)
+	if retryable {
+		t.Fatalf("expected a 404 to not be retryable")
+	}
+}
+
+func TestClassifyErrorRetryAfterHTTPDate(t *testing.T) {
+	when := time.Now().Add(45 * time.Second).Truncate(time.Second)
+	err := &googleapi.Error{
+		Code:   http.StatusServiceUnavailable,
+		Header: http.Header{"Retry-After": []string{when.UTC().Format(http.TimeFormat)}},
+	}
+	retryAfter, retryable := classifyError(err)
+	if !retryable {
+		t.Fatalf("expected 503 to be retryable")
+	}
+	// Allow a little slack since classifyError computes time.Until internally.
+	if retryAfter <= 0 || retryAfter > 46*time.Second {
+		t.Fatalf("expected a Retry-After around 45s, got %v", retryAfter)
+	}
+}
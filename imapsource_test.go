@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestXOAuth2ClientStart(t *testing.T) {
+	c := &xoauth2Client{username: "user@example.com", token: "ya29.sometoken"}
+	mech, ir, err := c.Start()
+	if err != nil {
+		t.Fatalf("Start returned an error: %v", err)
+	}
+	if mech != "XOAUTH2" {
+		t.Fatalf("expected mechanism %q, got %q", "XOAUTH2", mech)
+	}
+	want := "user=user@example.com\x01auth=Bearer ya29.sometoken\x01\x01"
+	if string(ir) != want {
+		t.Fatalf("expected initial response %q, got %q", want, ir)
+	}
+}
+
+func TestXOAuth2ClientNextErrors(t *testing.T) {
+	c := &xoauth2Client{username: "user@example.com", token: "ya29.sometoken"}
+	if _, err := c.Next([]byte("some challenge")); err == nil {
+		t.Fatalf("expected Next to error on any server challenge")
+	}
+}
+
+func TestParseFromAndSubject(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		wantFrom    string
+		wantSubject string
+	}{
+		{
+			name:        "both headers present",
+			header:      "From: spammer@example.com\r\nSubject: Buy now\r\n\r\n",
+			wantFrom:    "spammer@example.com",
+			wantSubject: "Buy now",
+		},
+		{
+			name:        "missing subject",
+			header:      "From: spammer@example.com\r\n\r\n",
+			wantFrom:    "spammer@example.com",
+			wantSubject: "",
+		},
+		{
+			name:        "malformed header",
+			header:      "not a valid header block",
+			wantFrom:    "",
+			wantSubject: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, subject := parseFromAndSubject(strings.NewReader(tt.header))
+			if from != tt.wantFrom {
+				t.Errorf("From: expected %q, got %q", tt.wantFrom, from)
+			}
+			if subject != tt.wantSubject {
+				t.Errorf("Subject: expected %q, got %q", tt.wantSubject, subject)
+			}
+		})
+	}
+}
@@ -5,276 +5,213 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"math/rand"
+	"net/mail"
 	"os"
-	"sort"
-	"sync"
+	"strings"
 	"time"
+	_ "time/tzdata"
 
 	"golang.org/x/oauth2/google"
-	"golang.org/x/sync/errgroup"
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/option"
 )
 
 var timeout = flag.Int("timeout", 60, "timeout in seconds")
-var initialDelay = flag.Int("initial-delay", 1000, "max initial delay in milliseconds before starting to fetch messages")
 var days = flag.Int("days", 30, "number of days to look back")
 var debug = flag.Bool("debug", false, "enable debug output")
+var by = flag.String("by", "date", "how to group the report: date, sender, or domain")
+var cacheFile = flag.String("cache-file", "spamcache.db", "path to the local cache of fetched message metadata")
+var refresh = flag.Bool("refresh", false, "ignore the local cache and re-fetch every message")
+var backend = flag.String("backend", "gmail", "mail backend to use: gmail or imap")
+var imapHost = flag.String("imap-host", "", "IMAP server address (host:port), required for -backend=imap")
+var imapUser = flag.String("imap-user", "", "IMAP username, required for -backend=imap")
+var imapFolder = flag.String("imap-folder", "Spam", "IMAP folder to scan for -backend=imap")
+var imapXOAuth2 = flag.Bool("imap-xoauth2", false, "authenticate to the IMAP server with XOAUTH2 instead of a password")
+var tz = flag.String("tz", "", "timezone to bucket messages by, e.g. America/New_York (defaults to the system local timezone)")
+var output = flag.String("output", "text", "report format: text, json, csv, or prom")
 var cutoffDate string
 
-func getSpamCounts(ctx context.Context, srv *gmail.Service) (map[string]int, error) {
-	dailyCounts := make(map[string]int)
+// spamSummary holds every aggregation getSpamCounts computes over a batch of
+// spam messages, so callers can render whichever one -by asks for without
+// re-fetching.
+type spamSummary struct {
+	dailyCounts  map[string]int
+	senderCounts map[string]int
+	domainCounts map[string]int
+}
+
+func getSpamCounts(ctx context.Context, src SpamSource, since time.Time, loc *time.Location) (*spamSummary, error) {
+	summary := &spamSummary{
+		dailyCounts:  make(map[string]int),
+		senderCounts: make(map[string]int),
+		domainCounts: make(map[string]int),
+	}
 
-	// Get all messages in the SPAM folder
-	messages, err := listSpamMessages(ctx, srv)
+	// Get all messages in the spam folder
+	messages, err := src.ListSpamSince(ctx, since)
 	if err != nil {
 		return nil, fmt.Errorf("unable to list spam messages: %v", err)
 	}
 
 	if len(messages) == 0 {
 		fmt.Println("No spam messages found.")
-		return dailyCounts, nil
+		return summary, nil
 	}
 
-	// Process each message to extract internalDate
+	// Process each message to extract internalDate and sender
 	for _, m := range messages {
-		// internalDate is returned as milliseconds since epoch (assumed to be UTC/GMT)
-		internalDateMs := m.InternalDate
-
-		// Safety check for invalid dates
-		if internalDateMs <= 0 {
+		emailDate := InternalDateToDate(m.InternalDate, loc)
+		if emailDate == "" {
 			if *debug {
-				log.Printf("Warning: Invalid internalDate (%d) for message ID %s", internalDateMs, m.Id)
+				log.Printf("Warning: Invalid internalDate (%d) for message ID %s", m.InternalDate, m.ID)
 			}
 			continue
 		}
 
-		// Create a time.Time object from the UTC epoch milliseconds.
-		// time.UnixMilli converts the UTC epoch milliseconds to a time.Time object
-		// representing that instant in the local system timezone.
-		// Convert the milliseconds-since-epoch to local time to get the correct
-		// local date (avoids off-by-one-day due to timezone differences).
-		emailTimeLocal := time.UnixMilli(internalDateMs).In(time.Local)
-
-		// Format the local time to get the local date string in YYYY-MM-DD format
-		emailDate := emailTimeLocal.Format("2006-01-02")
-
-		dailyCounts[emailDate]++
-	}
-
-	return dailyCounts, nil
-}
+		summary.dailyCounts[emailDate]++
 
-func listSpamMessages(ctx context.Context, srv *gmail.Service) ([]*gmail.Message, error) {
-	var messages []*gmail.Message
-	pageToken := ""
-
-	// We'll collect full messages into `messages` but fetch them using a
-	// bounded worker pool to avoid launching an unbounded number of
-	// goroutines. Use errgroup for easier error handling.
-
-	// Calculate the date 'days' ago
-	query := "after:" + cutoffDate // Gmail query to filter messages
-	fmt.Printf("Gmail query: %s\n", query)
-	total := 0
-
-	// Use a cancellable context with timeout so the whole listing/fetching
-	// process respects the -timeout flag.
-	ctx, cancel := context.WithTimeout(ctx, time.Duration(*timeout)*time.Second)
-	defer cancel()
-
-	// Bounded concurrency for fetching full messages
-	const maxWorkers = 8
-	sem := make(chan struct{}, maxWorkers)
-
-	var mu sync.Mutex
-	var eg errgroup.Group
-
-	for {
-		req := srv.Users.Messages.List("me").LabelIds("SPAM").Q(query)
-		if pageToken != "" {
-			req = req.PageToken(pageToken)
+		if m.From == "" {
+			continue
 		}
-
-		var listResp *gmail.ListMessagesResponse
-		// Wrap the request with a context check so we exit quickly if the
-		// parent context is cancelled.
-		if err := retryWithBackoff(ctx, func() error {
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			default:
-			}
-			var err error
-			listResp, err = req.Do()
-			if err != nil && *debug {
-				log.Printf("Error fetching messages list: %v", err)
+		addr, err := mail.ParseAddress(m.From)
+		if err != nil {
+			if *debug {
+				log.Printf("Warning: unable to parse From header %q for message ID %s: %v", m.From, m.ID, err)
 			}
-			return err
-		}); err != nil {
-			return nil, fmt.Errorf("error fetching messages: %v", err)
-		}
-
-		// Process messages with bounded concurrency
-		for _, msg := range listResp.Messages {
-			m := msg
-			total++
-			fmt.Printf("\r%d", total)
-
-			sem <- struct{}{}
-			eg.Go(func() error {
-				defer func() { <-sem }()
-
-				// delay a random interval between 0 and initialDelay milliseconds to avoid hitting rate limits
-				time.Sleep(time.Duration(rand.Intn(*initialDelay)) * time.Millisecond)
-
-				var fullMsg *gmail.Message
-				if err := retryWithBackoff(ctx, func() error {
-					select {
-					case <-ctx.Done():
-						return ctx.Err()
-					default:
-					}
-					var err error
-					fullMsg, err = srv.Users.Messages.Get("me", m.Id).Format("minimal").Do()
-					if err != nil && *debug {
-						log.Printf("Error fetching message %s: %v", m.Id, err)
-					}
-					return err
-				}); err != nil {
-					if *debug {
-						log.Printf("Failed to fetch message %s: %v", m.Id, err)
-					}
-					return nil // non-fatal; continue with other messages
-				}
-
-				if fullMsg != nil {
-					mu.Lock()
-					messages = append(messages, fullMsg)
-					mu.Unlock()
-				}
-				return nil
-			})
+			continue
 		}
-
-		pageToken = listResp.NextPageToken
-		if pageToken == "" {
-			break
+		address := strings.ToLower(addr.Address)
+		summary.senderCounts[address]++
+		if domain := domainOf(address); domain != "" {
+			summary.domainCounts[domain]++
 		}
 	}
 
-	fmt.Print("\r") // erase the in progress count
+	return summary, nil
+}
 
-	// Wait for all workers to finish (or context timeout)
-	if err := eg.Wait(); err != nil {
-		return nil, err
+// domainOf returns the part of an email address after the "@", or "" if
+// address isn't in user@domain form.
+func domainOf(address string) string {
+	i := strings.LastIndex(address, "@")
+	if i < 0 || i == len(address)-1 {
+		return ""
 	}
-
-	return messages, nil
+	return address[i+1:]
 }
 
-type outputStates int
-
-const (
-	FirstLine outputStates = iota
-	BeforeDate
-	OnOrAfterDate
-)
-
-func printSpamSummary(spamCounts map[string]int) {
-	var dates []string
-	for date := range spamCounts {
-		dates = append(dates, date)
-	}
-	sort.Strings(dates)
+// cutoffSince returns the instant `days` days before now, the start of the
+// window getSpamCounts reports on.
+func cutoffSince(now time.Time, days int) time.Time {
+	return now.AddDate(0, 0, -days)
+}
 
-	total := 0
-	outputState := FirstLine
-	for _, date := range dates {
-		if date < cutoffDate {
-			outputState = BeforeDate
-			// log.Default().Printf("Switching to BEFORE_DATE for date: %s\n", date)
-		} else {
-			if outputState == BeforeDate {
-				// Print a blank line to separate sections
-				fmt.Println()
-			}
-			outputState = OnOrAfterDate
-		}
+// internalDateToDate formats a Gmail internalDate (milliseconds since the
+// Unix epoch, UTC) as a YYYY-MM-DD date string in the system's local
+// timezone. It returns "" for a non-positive (i.e. missing) timestamp.
+func internalDateToDate(ms int64) string {
+	return InternalDateToDate(ms, time.Local)
+}
 
-		count := spamCounts[date]
-		total += count
-		dateValue, err := time.Parse("2006-01-02", date)
-		if err != nil {
-			log.Printf("Error parsing date: %v", err)
-			continue
-		}
-		dayOfWeek := dateValue.Format("Mon")
-		fmt.Printf("%s %s %d\n", dayOfWeek, date, count)
+// InternalDateToDate formats a Gmail internalDate (milliseconds since the
+// Unix epoch, UTC) as a YYYY-MM-DD date string in loc. It returns "" for a
+// non-positive (i.e. missing) timestamp.
+func InternalDateToDate(ms int64, loc *time.Location) string {
+	if ms <= 0 {
+		return ""
 	}
-	fmt.Printf("Total: %d\n", total)
+	return time.UnixMilli(ms).In(loc).Format("2006-01-02")
 }
 
 func main() {
 	flag.Parse()
-	cutoffDate = time.Now().AddDate(0, 0, -*days).Format("2006-01-02")
 
-	// Seed the random number generator used for jitter delays
-	rand.Seed(time.Now().UnixNano())
+	switch *by {
+	case "date", "sender", "domain":
+	default:
+		log.Fatalf("Invalid -by value %q: must be one of date, sender, domain", *by)
+	}
 
-	ctx := context.Background()
-	b, err := os.ReadFile("credentials.json") // Download from Google Cloud Console
-	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
+	switch *output {
+	case "text", "json", "csv", "prom":
+	default:
+		log.Fatalf("Invalid -output value %q: must be one of text, json, csv, prom", *output)
 	}
 
-	// If modifying these scopes, delete your previously saved token.json.
-	config, err := google.ConfigFromJSON(b, gmail.GmailReadonlyScope)
-	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
+	loc := time.Local
+	if *tz != "" {
+		var err error
+		loc, err = time.LoadLocation(*tz)
+		if err != nil {
+			log.Fatalf("Invalid -tz value %q: %v", *tz, err)
+		}
 	}
-	client := getClient(ctx, config)
 
-	srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+	since := cutoffSince(clock.Now().In(loc), *days)
+	cutoffDate = since.Format("2006-01-02")
+
+	ctx := context.Background()
+	src, closeSrc, err := newSpamSource(ctx)
 	if err != nil {
-		log.Fatalf("Unable to retrieve Gmail client: %v", err)
+		log.Fatalf("Unable to set up %s backend: %v", *backend, err)
 	}
+	defer closeSrc()
 
-	spamCounts, err := getSpamCounts(ctx, srv)
+	summary, err := getSpamCounts(ctx, src, since, loc)
 	if err != nil {
 		log.Fatalf("Error getting spam counts: %v", err)
 	}
 
-	fmt.Printf("Spam email counts for the past %v days (based on internalDate):\n", *days)
-	printSpamSummary(spamCounts)
+	if *output == "text" {
+		fmt.Printf("Spam email report for the past %v days (based on internalDate), grouped by %s:\n", *days, *by)
+	}
+	printSpamSummary(summary, loc)
 }
 
-// retryWithBackoff retries the provided operation with exponential backoff
-// until it succeeds or the context is cancelled.
-func retryWithBackoff(ctx context.Context, op func() error) error {
-	wait := 300 * time.Millisecond
-	maxAttempts := 8
-	for i := 0; i < maxAttempts; i++ {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+// newSpamSource builds the SpamSource selected by -backend, along with a
+// cleanup function the caller must invoke once done with it.
+func newSpamSource(ctx context.Context) (SpamSource, func(), error) {
+	switch *backend {
+	case "gmail":
+		b, err := os.ReadFile("credentials.json") // Download from Google Cloud Console
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read client secret file: %v", err)
 		}
 
-		if err := op(); err == nil {
-			return nil
-		} else {
-			if i == maxAttempts-1 {
-				return err
-			}
-			jitter := time.Duration(rand.Intn(200)) * time.Millisecond
-			time.Sleep(wait + jitter)
-			wait *= 2
-			if wait > 10*time.Second {
-				wait = 10 * time.Second
-			}
+		// If modifying these scopes, delete your previously saved token.json.
+		config, err := google.ConfigFromJSON(b, gmail.GmailReadonlyScope)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to parse client secret file to config: %v", err)
+		}
+		client, err := getClient(ctx, config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to get OAuth client: %v", err)
+		}
+
+		srv, err := gmail.NewService(ctx, option.WithHTTPClient(client))
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to retrieve Gmail client: %v", err)
 		}
+
+		db, err := openCache(*cacheFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to open cache: %v", err)
+		}
+
+		return NewGmailSource(srv, db), func() { db.Close() }, nil
+
+	case "imap":
+		if *imapHost == "" || *imapUser == "" {
+			return nil, nil, fmt.Errorf("-imap-host and -imap-user are required for -backend=imap")
+		}
+		password := os.Getenv("IMAP_PASSWORD")
+		if password == "" {
+			return nil, nil, fmt.Errorf("IMAP_PASSWORD environment variable must be set for -backend=imap")
+		}
+		src := NewIMAPSource(*imapHost, *imapUser, password, *imapFolder, *imapXOAuth2)
+		return src, func() {}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown -backend %q: must be gmail or imap", *backend)
 	}
-	return fmt.Errorf("retry attempts exhausted")
 }
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// topN is how many rows to show in the -by=sender and -by=domain reports.
+const topN = 20
+
+// keyCount is one row of the top-senders/top-domains report.
+type keyCount struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// dayCount is one row of the date-grouped report.
+type dayCount struct {
+	Date    string `json:"date"`
+	Weekday string `json:"weekday"`
+	Count   int    `json:"count"`
+}
+
+// dailyReport is the -output=json payload for -by=date.
+type dailyReport struct {
+	Cutoff string     `json:"cutoff"`
+	TZ     string     `json:"tz"`
+	Days   []dayCount `json:"days"`
+	Total  int        `json:"total"`
+}
+
+// keyReport is the -output=json payload for -by=sender and -by=domain.
+type keyReport struct {
+	Group string     `json:"group"`
+	Rows  []keyCount `json:"rows"`
+	Total int        `json:"total"`
+}
+
+func printSpamSummary(summary *spamSummary, loc *time.Location) {
+	switch *by {
+	case "sender":
+		printKeyCounts(summary.senderCounts, "sender")
+	case "domain":
+		printKeyCounts(summary.domainCounts, "domain")
+	default:
+		printDailySummary(summary.dailyCounts, loc)
+	}
+}
+
+func printDailySummary(spamCounts map[string]int, loc *time.Location) {
+	var dates []string
+	for date := range spamCounts {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	total := 0
+	days := make([]dayCount, 0, len(dates))
+	for _, date := range dates {
+		count := spamCounts[date]
+		total += count
+		dateValue, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			log.Printf("Error parsing date: %v", err)
+			continue
+		}
+		days = append(days, dayCount{
+			Date:    date,
+			Weekday: dateValue.Format("Mon"),
+			Count:   count,
+		})
+	}
+
+	tzName := "Local"
+	if loc != nil {
+		tzName = loc.String()
+	}
+
+	switch *output {
+	case "json":
+		printDailyJSON(os.Stdout, days, total, tzName)
+	case "csv":
+		printDailyCSV(os.Stdout, days)
+	case "prom":
+		printDailyProm(os.Stdout, days)
+	default:
+		printDailyText(os.Stdout, days, total)
+	}
+}
+
+// printDailyText prints one line per day, with a blank line separating days
+// before cutoffDate from days on or after it.
+func printDailyText(w io.Writer, days []dayCount, total int) {
+	outputState := FirstLine
+	for _, d := range days {
+		if d.Date < cutoffDate {
+			outputState = BeforeDate
+		} else {
+			if outputState == BeforeDate {
+				// Print a blank line to separate sections
+				fmt.Fprintln(w)
+			}
+			outputState = OnOrAfterDate
+		}
+		fmt.Fprintf(w, "%s %s %d\n", d.Weekday, d.Date, d.Count)
+	}
+	fmt.Fprintf(w, "Total: %d\n", total)
+}
+
+func printDailyJSON(w io.Writer, days []dayCount, total int, tzName string) {
+	report := dailyReport{
+		Cutoff: cutoffDate,
+		TZ:     tzName,
+		Days:   days,
+		Total:  total,
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		log.Printf("Error encoding JSON report: %v", err)
+	}
+}
+
+func printDailyCSV(w io.Writer, days []dayCount) {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+	for _, d := range days {
+		cw.Write([]string{d.Date, d.Weekday, fmt.Sprintf("%d", d.Count)})
+	}
+}
+
+func printDailyProm(w io.Writer, days []dayCount) {
+	for _, d := range days {
+		fmt.Fprintf(w, "spam_messages_total{date=%q} %d\n", d.Date, d.Count)
+	}
+}
+
+type outputStates int
+
+const (
+	FirstLine outputStates = iota
+	BeforeDate
+	OnOrAfterDate
+)
+
+// printKeyCounts prints the top N keys in counts, sorted by count descending
+// (ties broken alphabetically), under the given group label.
+func printKeyCounts(counts map[string]int, group string) {
+	rows := make([]keyCount, 0, len(counts))
+	for key, count := range counts {
+		rows = append(rows, keyCount{Key: key, Count: count})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].Key < rows[j].Key
+	})
+
+	total := 0
+	for _, r := range rows {
+		total += r.Count
+	}
+
+	top := rows
+	if len(top) > topN {
+		top = top[:topN]
+	}
+
+	switch *output {
+	case "json":
+		printKeyJSON(os.Stdout, top, total, group)
+	case "csv":
+		printKeyCSV(os.Stdout, top)
+	case "prom":
+		printKeyProm(os.Stdout, top, group)
+	default:
+		printKeyText(os.Stdout, top, total, group)
+	}
+}
+
+func printKeyText(w io.Writer, rows []keyCount, total int, group string) {
+	fmt.Fprintf(w, "Top %d by %s:\n", len(rows), group)
+	for _, r := range rows {
+		fmt.Fprintf(w, "%5d  %s\n", r.Count, r.Key)
+	}
+	fmt.Fprintf(w, "Total: %d\n", total)
+}
+
+func printKeyJSON(w io.Writer, rows []keyCount, total int, group string) {
+	report := keyReport{Group: group, Rows: rows, Total: total}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		log.Printf("Error encoding JSON report: %v", err)
+	}
+}
+
+func printKeyCSV(w io.Writer, rows []keyCount) {
+	cw := csv.NewWriter(w)
+	defer cw.Flush()
+	for _, r := range rows {
+		cw.Write([]string{r.Key, fmt.Sprintf("%d", r.Count)})
+	}
+}
+
+func printKeyProm(w io.Writer, rows []keyCount, group string) {
+	for _, r := range rows {
+		fmt.Fprintf(w, "spam_messages_total{%s=%q} %d\n", group, r.Key, r.Count)
+	}
+}
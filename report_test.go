@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintDailyJSON(t *testing.T) {
+	days := []dayCount{{Date: "2024-03-01", Weekday: "Fri", Count: 2}}
+	var buf bytes.Buffer
+	printDailyJSON(&buf, days, 2, "UTC")
+
+	for _, want := range []string{`"cutoff"`, `"tz": "UTC"`, `"date": "2024-03-01"`, `"total": 2`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected output to contain %q, got %s", want, buf.String())
+		}
+	}
+}
+
+func TestPrintDailyCSV(t *testing.T) {
+	days := []dayCount{{Date: "2024-03-01", Weekday: "Fri", Count: 2}}
+	var buf bytes.Buffer
+	printDailyCSV(&buf, days)
+
+	want := "2024-03-01,Fri,2\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestPrintDailyProm(t *testing.T) {
+	days := []dayCount{{Date: "2024-03-01", Count: 2}}
+	var buf bytes.Buffer
+	printDailyProm(&buf, days)
+
+	want := "spam_messages_total{date=\"2024-03-01\"} 2\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestPrintKeyJSON(t *testing.T) {
+	rows := []keyCount{{Key: "spammer@example.com", Count: 3}}
+	var buf bytes.Buffer
+	printKeyJSON(&buf, rows, 3, "sender")
+
+	for _, want := range []string{`"group": "sender"`, `"key": "spammer@example.com"`, `"total": 3`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected output to contain %q, got %s", want, buf.String())
+		}
+	}
+}
+
+func TestPrintKeyCSV(t *testing.T) {
+	rows := []keyCount{{Key: "spammer@example.com", Count: 3}}
+	var buf bytes.Buffer
+	printKeyCSV(&buf, rows)
+
+	want := "spammer@example.com,3\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestPrintKeyProm(t *testing.T) {
+	rows := []keyCount{{Key: "spammer@example.com", Count: 3}}
+	var buf bytes.Buffer
+	printKeyProm(&buf, rows, "sender")
+
+	want := "spam_messages_total{sender=\"spammer@example.com\"} 3\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestPrintKeyText(t *testing.T) {
+	rows := []keyCount{{Key: "spammer@example.com", Count: 3}}
+	var buf bytes.Buffer
+	printKeyText(&buf, rows, 3, "sender")
+
+	if !strings.HasPrefix(buf.String(), "Top 1 by sender:\n") {
+		t.Fatalf("expected header line naming the row count and group, got %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "Total: 3\n") {
+		t.Fatalf("expected a total line, got %s", buf.String())
+	}
+}
+
+func TestPrintDailyText(t *testing.T) {
+	orig := cutoffDate
+	cutoffDate = "2024-03-01"
+	defer func() { cutoffDate = orig }()
+
+	days := []dayCount{
+		{Date: "2024-02-28", Weekday: "Wed", Count: 1},
+		{Date: "2024-03-01", Weekday: "Fri", Count: 2},
+	}
+	var buf bytes.Buffer
+	printDailyText(&buf, days, 3)
+
+	want := "Wed 2024-02-28 1\n\nFri 2024-03-01 2\nTotal: 3\n"
+	if buf.String() != want {
+		t.Fatalf("expected %q, got %q", want, buf.String())
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// SpamMessage is the subset of a spam message's metadata that every
+// SpamSource reports, regardless of which mail backend produced it.
+type SpamMessage struct {
+	ID           string
+	InternalDate int64 // milliseconds since the Unix epoch, UTC
+	From         string
+	Subject      string
+}
+
+// SpamSource lists messages sitting in a spam/junk folder. Implementations
+// exist for the Gmail API (GmailSource) and generic IMAP (IMAPSource).
+type SpamSource interface {
+	// ListSpamSince returns every spam message received on or after since.
+	ListSpamSince(ctx context.Context, since time.Time) ([]SpamMessage, error)
+}